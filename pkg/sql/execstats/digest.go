@@ -0,0 +1,150 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package execstats
+
+import (
+	"sort"
+	"time"
+)
+
+// digestCompression bounds the number of centroids a durationDigest
+// retains, trading accuracy for a small, fixed per-processor memory
+// footprint.
+const digestCompression = 100
+
+// centroid is a single (mean, weight) pair in a durationDigest, in the style
+// of the t-digest data structure (Dunning & Ertl, "Computing Extremely
+// Accurate Quantiles Using t-Digests"). durationDigest does not use the
+// t-digest scale function: compress merges equal-length runs of centroids
+// regardless of where they fall, so the tails are kept no finer than the
+// middle.
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// durationDigest is a small, mergeable centroid digest over time.Duration
+// values.
+// It supports approximate quantile queries (p50/p95/p99) and merges
+// associatively, which is what lets QueryLevelStats.Accumulate fold digests
+// from multiple flows and nodes without re-deriving percentiles from raw
+// observations.
+type durationDigest struct {
+	centroids []centroid
+}
+
+// Add records a single observation with the given weight (typically 1).
+func (d *durationDigest) Add(value time.Duration, weight float64) {
+	d.centroids = append(d.centroids, centroid{mean: float64(value), weight: weight})
+	d.compress()
+}
+
+// Merge folds other's observations into d. Merge is associative and
+// commutative, so digests can be combined in any order across nodes and
+// flows. other is not modified.
+func (d *durationDigest) Merge(other *durationDigest) {
+	if other == nil {
+		return
+	}
+	d.centroids = append(d.centroids, other.centroids...)
+	d.compress()
+}
+
+// compress sorts centroids by mean and, once there are more than
+// digestCompression of them, merges runs of adjacent centroids of equal
+// count into exactly digestCompression centroids. This keeps the digest's
+// size bounded
+// regardless of how many observations have been added or merged in.
+func (d *durationDigest) compress() {
+	sort.Slice(d.centroids, func(i, j int) bool { return d.centroids[i].mean < d.centroids[j].mean })
+	if len(d.centroids) <= digestCompression {
+		return
+	}
+
+	merged := make([]centroid, 0, digestCompression)
+	step := float64(len(d.centroids)) / float64(digestCompression)
+	for i := 0; i < digestCompression; i++ {
+		start := int(float64(i) * step)
+		end := int(float64(i+1) * step)
+		if end > len(d.centroids) {
+			end = len(d.centroids)
+		}
+		if start >= end {
+			continue
+		}
+		var sumWeight, sumMean float64
+		for _, c := range d.centroids[start:end] {
+			sumWeight += c.weight
+			sumMean += c.mean * c.weight
+		}
+		merged = append(merged, centroid{mean: sumMean / sumWeight, weight: sumWeight})
+	}
+	d.centroids = merged
+}
+
+// Quantile returns an approximation of the qth quantile (0 <= q <= 1) of the
+// values added to or merged into the digest. It returns 0 if the digest is
+// empty.
+func (d *durationDigest) Quantile(q float64) time.Duration {
+	if len(d.centroids) == 0 {
+		return 0
+	}
+	var totalWeight float64
+	for _, c := range d.centroids {
+		totalWeight += c.weight
+	}
+	target := q * totalWeight
+	var cumulative float64
+	for _, c := range d.centroids {
+		cumulative += c.weight
+		if cumulative >= target {
+			return time.Duration(c.mean)
+		}
+	}
+	return time.Duration(d.centroids[len(d.centroids)-1].mean)
+}
+
+// mergeDigests returns a digest containing the union of a and b's
+// observations. b is never modified. If a is non-nil, its centroids are
+// merged in place and a itself is returned, so callers must not keep using
+// a handle to a afterward expecting it to still reflect only its original
+// observations. Either argument may be nil.
+func mergeDigests(a, b *durationDigest) *durationDigest {
+	if b == nil {
+		return a
+	}
+	if a == nil {
+		d := &durationDigest{}
+		d.Merge(b)
+		return d
+	}
+	a.Merge(b)
+	return a
+}
+
+// DurationPercentiles holds p50/p95/p99 approximations for a
+// duration-valued stat, as computed from a durationDigest.
+type DurationPercentiles struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+func percentilesFromDigest(d *durationDigest) DurationPercentiles {
+	if d == nil {
+		return DurationPercentiles{}
+	}
+	return DurationPercentiles{
+		P50: d.Quantile(0.5),
+		P95: d.Quantile(0.95),
+		P99: d.Quantile(0.99),
+	}
+}
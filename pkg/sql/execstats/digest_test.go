@@ -0,0 +1,70 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package execstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationDigestQuantile(t *testing.T) {
+	d := &durationDigest{}
+	for i := 1; i <= 100; i++ {
+		d.Add(time.Duration(i)*time.Millisecond, 1)
+	}
+	require.InDelta(t, 50*time.Millisecond, d.Quantile(0.5), float64(2*time.Millisecond))
+	require.InDelta(t, 99*time.Millisecond, d.Quantile(0.99), float64(2*time.Millisecond))
+}
+
+// TestMergeDigestsAssociative verifies that merging two digests produces the
+// same quantiles as a single digest built from the union of their
+// observations, which is the whole point of using a mergeable digest
+// instead of re-deriving percentiles from raw observations per node.
+func TestMergeDigestsAssociative(t *testing.T) {
+	a := &durationDigest{}
+	for i := 1; i <= 50; i++ {
+		a.Add(time.Duration(i)*time.Millisecond, 1)
+	}
+	b := &durationDigest{}
+	for i := 51; i <= 100; i++ {
+		b.Add(time.Duration(i)*time.Millisecond, 1)
+	}
+
+	merged := mergeDigests(a, b)
+
+	combined := &durationDigest{}
+	for i := 1; i <= 100; i++ {
+		combined.Add(time.Duration(i)*time.Millisecond, 1)
+	}
+
+	require.InDelta(t, combined.Quantile(0.5), merged.Quantile(0.5), float64(2*time.Millisecond))
+	require.InDelta(t, combined.Quantile(0.99), merged.Quantile(0.99), float64(2*time.Millisecond))
+}
+
+func TestMergeDigestsNilArguments(t *testing.T) {
+	require.Nil(t, mergeDigests(nil, nil))
+
+	a := &durationDigest{}
+	a.Add(time.Second, 1)
+	require.Same(t, a, mergeDigests(a, nil))
+
+	b := &durationDigest{}
+	b.Add(time.Second, 1)
+	merged := mergeDigests(nil, b)
+	require.NotSame(t, b, merged)
+	require.Equal(t, b.Quantile(0.5), merged.Quantile(0.5))
+}
+
+func TestPercentilesFromNilDigest(t *testing.T) {
+	require.Equal(t, DurationPercentiles{}, percentilesFromDigest(nil))
+}
@@ -0,0 +1,72 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package execstats
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
+)
+
+// StatExtractor lets a package contribute a named family of stats to
+// NodeLevelStats and QueryLevelStats without modifying this file.
+// Extractors are stateless: they accumulate values directly into the
+// NodeLevelStats and QueryLevelStats instances that TraceAnalyzer hands
+// them, so a single registered StatExtractor is shared safely across every
+// TraceAnalyzer.
+//
+// Registered extractors are dispatched to from TraceAnalyzer.ProcessStats.
+// TraceAnalyzer.AddTrace dispatches on component type too, but through the
+// separate componentFilers table rather than this registry: what AddTrace
+// routes is raw stats into one of three differently-typed FlowMetadata maps
+// (a structural concern), not a named stat a StatExtractor computes.
+type StatExtractor interface {
+	// ComponentTypes returns the component types this extractor consumes
+	// stats for. TraceAnalyzer.ProcessStats only invokes Extract for
+	// components whose type appears here.
+	ComponentTypes() []execinfrapb.ComponentID_Type
+
+	// Extract is called once for every component found in a trace whose
+	// type is one of ComponentTypes, and should merge whatever it needs
+	// from stats into node, grouped under nodeID. Implementations must
+	// write through node.AddExtended rather than indexing into
+	// node.Extended directly, since it isn't pre-allocated.
+	Extract(node *NodeLevelStats, nodeID roachpb.NodeID, stats *execinfrapb.ComponentStats)
+
+	// Accumulate folds the values this extractor contributed to node
+	// across every node into query. It is called once per ProcessStats
+	// call, after every component has been extracted. Implementations
+	// must write through query.AddExtended rather than indexing into
+	// query.Extended directly, since it isn't pre-allocated.
+	Accumulate(node NodeLevelStats, query *QueryLevelStats)
+}
+
+var extractorsByComponentType = map[execinfrapb.ComponentID_Type][]StatExtractor{}
+var registeredExtractors []StatExtractor
+var extractorNames = map[string]struct{}{}
+
+// RegisterExtractor registers e under name so that TraceAnalyzer.ProcessStats
+// dispatches to it for every component whose type is one of
+// e.ComponentTypes(). It is meant to be called from the init function of a
+// package that wants to contribute its own named stats (counters, gauges,
+// durations) without this package needing to know about it; callers
+// blank-import that package to run its init.
+//
+// RegisterExtractor panics if name is already registered.
+func RegisterExtractor(name string, e StatExtractor) {
+	if _, ok := extractorNames[name]; ok {
+		panic("execstats: extractor " + name + " already registered")
+	}
+	extractorNames[name] = struct{}{}
+	registeredExtractors = append(registeredExtractors, e)
+	for _, t := range e.ComponentTypes() {
+		extractorsByComponentType[t] = append(extractorsByComponentType[t], e)
+	}
+}
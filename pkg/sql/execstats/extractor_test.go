@@ -0,0 +1,81 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package execstats
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStatExtractor is a StatExtractor that records how many times Extract
+// was called and contributes a fixed value per node, so tests can assert on
+// registry dispatch without needing a real domain-specific stat.
+type fakeStatExtractor struct {
+	componentTypes []execinfrapb.ComponentID_Type
+	extractCalls   int
+}
+
+func (f *fakeStatExtractor) ComponentTypes() []execinfrapb.ComponentID_Type {
+	return f.componentTypes
+}
+
+func (f *fakeStatExtractor) Extract(
+	node *NodeLevelStats, nodeID roachpb.NodeID, stats *execinfrapb.ComponentStats,
+) {
+	f.extractCalls++
+	node.AddExtended("fake-extractor", nodeID, 1)
+}
+
+func (f *fakeStatExtractor) Accumulate(node NodeLevelStats, query *QueryLevelStats) {
+	for _, v := range node.Extended["fake-extractor"] {
+		query.AddExtended("fake-extractor", v)
+	}
+}
+
+// TestProcessStatsDispatchesToRegisteredExtractor verifies the end-to-end
+// registry path: RegisterExtractor makes a StatExtractor reachable from
+// ProcessStats, ProcessStats calls Extract for every component of a type the
+// extractor declared, and the values Extract contributed are folded into
+// QueryLevelStats via Accumulate, all without ProcessStats or
+// QueryLevelStats.Accumulate knowing about "fake-extractor" by name.
+func TestProcessStatsDispatchesToRegisteredExtractor(t *testing.T) {
+	ext := &fakeStatExtractor{componentTypes: []execinfrapb.ComponentID_Type{execinfrapb.ComponentID_PROCESSOR}}
+	RegisterExtractor("test-fake-extractor", ext)
+
+	a := &TraceAnalyzer{
+		FlowMetadata: &FlowMetadata{
+			processorStats: map[execinfrapb.ProcessorID]*processorStats{
+				1: {nodeID: 1, stats: &execinfrapb.ComponentStats{}},
+				2: {nodeID: 2, stats: &execinfrapb.ComponentStats{}},
+			},
+		},
+	}
+	require.NoError(t, a.ProcessStats())
+
+	require.Equal(t, 2, ext.extractCalls)
+	require.Equal(t, 1.0, a.GetNodeLevelStats().Extended["fake-extractor"][1])
+	require.Equal(t, 1.0, a.GetNodeLevelStats().Extended["fake-extractor"][2])
+	require.Equal(t, 2.0, a.GetQueryLevelStats().Extended["fake-extractor"])
+}
+
+// TestRegisterExtractorPanicsOnDuplicateName verifies that RegisterExtractor
+// panics rather than silently shadowing an existing extractor, since two
+// packages registering under the same name almost certainly indicates a
+// copy-paste mistake rather than an intentional override.
+func TestRegisterExtractorPanicsOnDuplicateName(t *testing.T) {
+	RegisterExtractor("test-duplicate-extractor", &fakeStatExtractor{})
+	require.Panics(t, func() {
+		RegisterExtractor("test-duplicate-extractor", &fakeStatExtractor{})
+	})
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package promexport exposes the per-node values of execstats.NodeLevelStats
+// as Prometheus collectors. Query-wide totals are the sum of these per-node
+// series; there is no separate recording of an execstats.QueryLevelStats.
+// This package registers its collectors with no registry and does not
+// connect them to the _status/vars endpoint.
+package promexport
+
+import (
+	"strconv"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "sql"
+const subsystem = "distsql"
+
+// kvTimeHistogramBuckets and contentionTimeHistogramBuckets bucket
+// per-query latencies logarithmically from 100us to ~52s, which covers the
+// range of KV round-trips and lock waits we expect to observe across a
+// fleet of nodes.
+var kvTimeHistogramBuckets = prometheus.ExponentialBuckets(0.0001, 2, 20)
+var contentionTimeHistogramBuckets = prometheus.ExponentialBuckets(0.0001, 2, 20)
+
+// Collectors holds Prometheus metrics for the per-node fields of
+// execstats.NodeLevelStats and itself implements prometheus.Collector.
+// Values are recorded keyed by the node ID label, giving the per-node
+// breakdown of a distributed query's execution stats. Collectors does not
+// register itself with any registry.
+type Collectors struct {
+	networkBytesSent *prometheus.CounterVec
+	kvBytesRead      *prometheus.CounterVec
+	kvRowsRead       *prometheus.CounterVec
+	kvTime           *prometheus.CounterVec
+	kvTimeHistogram  *prometheus.HistogramVec
+	networkMessages  *prometheus.CounterVec
+
+	contentionTime          *prometheus.CounterVec
+	contentionTimeHistogram *prometheus.HistogramVec
+
+	maxMemUsage *prometheus.GaugeVec
+}
+
+// NewCollectors constructs a Collectors with all of its metrics named under
+// the sql namespace and distsql subsystem (sql_distsql_*). It registers
+// nothing.
+func NewCollectors() *Collectors {
+	counterVec := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      name,
+			Help:      help,
+		}, []string{"node_id"})
+	}
+
+	return &Collectors{
+		networkBytesSent: counterVec(
+			"network_bytes_sent", "Total bytes sent over the network by distributed SQL execution",
+		),
+		kvBytesRead: counterVec(
+			"kv_bytes_read", "Total bytes read from KV by distributed SQL execution",
+		),
+		kvRowsRead: counterVec(
+			"kv_rows_read", "Total rows read from KV by distributed SQL execution",
+		),
+		kvTime: counterVec(
+			"kv_time_seconds", "Cumulative time spent waiting on KV by distributed SQL execution",
+		),
+		kvTimeHistogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "kv_time_seconds_histogram",
+			Help:      "Distribution of per-query time spent waiting on KV by distributed SQL execution",
+			Buckets:   kvTimeHistogramBuckets,
+		}, []string{"node_id"}),
+		networkMessages: counterVec(
+			"network_messages", "Total number of network messages sent by distributed SQL execution",
+		),
+		contentionTime: counterVec(
+			"contention_time_seconds", "Cumulative time spent in contention by distributed SQL execution",
+		),
+		contentionTimeHistogram: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "contention_time_seconds_histogram",
+			Help:      "Distribution of per-query time spent in contention by distributed SQL execution",
+			Buckets:   contentionTimeHistogramBuckets,
+		}, []string{"node_id"}),
+		maxMemUsage: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "max_mem_usage_bytes",
+			Help:      "Maximum memory usage observed for distributed SQL execution",
+		}, []string{"node_id"}),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c *Collectors) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *Collectors) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range c.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (c *Collectors) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		c.networkBytesSent,
+		c.kvBytesRead,
+		c.kvRowsRead,
+		c.kvTime,
+		c.kvTimeHistogram,
+		c.networkMessages,
+		c.contentionTime,
+		c.contentionTimeHistogram,
+		c.maxMemUsage,
+	}
+}
+
+// RecordNodeLevelStats pushes the per-node values accumulated in stats into
+// the corresponding collectors, labeled by node ID. It is intended to be
+// called after TraceAnalyzer.ProcessStats has populated a NodeLevelStats.
+func (c *Collectors) RecordNodeLevelStats(stats execstats.NodeLevelStats) {
+	for nodeID, v := range stats.NetworkBytesSentGroupedByNode {
+		c.networkBytesSent.WithLabelValues(nodeIDLabel(nodeID)).Add(float64(v))
+	}
+	for nodeID, v := range stats.KVBytesReadGroupedByNode {
+		c.kvBytesRead.WithLabelValues(nodeIDLabel(nodeID)).Add(float64(v))
+	}
+	for nodeID, v := range stats.KVRowsReadGroupedByNode {
+		c.kvRowsRead.WithLabelValues(nodeIDLabel(nodeID)).Add(float64(v))
+	}
+	for nodeID, v := range stats.KVTimeGroupedByNode {
+		label := nodeIDLabel(nodeID)
+		c.kvTime.WithLabelValues(label).Add(v.Seconds())
+		c.kvTimeHistogram.WithLabelValues(label).Observe(v.Seconds())
+	}
+	for nodeID, v := range stats.NetworkMessagesGroupedByNode {
+		c.networkMessages.WithLabelValues(nodeIDLabel(nodeID)).Add(float64(v))
+	}
+	for nodeID, v := range stats.ContentionTimeGroupedByNode {
+		label := nodeIDLabel(nodeID)
+		c.contentionTime.WithLabelValues(label).Add(v.Seconds())
+		c.contentionTimeHistogram.WithLabelValues(label).Observe(v.Seconds())
+	}
+	for nodeID, v := range stats.MaxMemoryUsageGroupedByNode {
+		c.maxMemUsage.WithLabelValues(nodeIDLabel(nodeID)).Set(float64(v))
+	}
+}
+
+func nodeIDLabel(nodeID roachpb.NodeID) string {
+	return strconv.Itoa(int(nodeID))
+}
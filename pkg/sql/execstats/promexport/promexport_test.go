@@ -0,0 +1,42 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package promexport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordNodeLevelStats(t *testing.T) {
+	c := NewCollectors()
+	c.RecordNodeLevelStats(execstats.NodeLevelStats{
+		KVBytesReadGroupedByNode:    map[roachpb.NodeID]int64{1: 100},
+		KVTimeGroupedByNode:         map[roachpb.NodeID]time.Duration{1: 2 * time.Second},
+		ContentionTimeGroupedByNode: map[roachpb.NodeID]time.Duration{1: 500 * time.Millisecond},
+		MaxMemoryUsageGroupedByNode: map[roachpb.NodeID]int64{1: 4096},
+	})
+
+	require.Equal(t, float64(100), testutil.ToFloat64(c.kvBytesRead.WithLabelValues("1")))
+	require.Equal(t, float64(2), testutil.ToFloat64(c.kvTime.WithLabelValues("1")))
+	require.Equal(t, 0.5, testutil.ToFloat64(c.contentionTime.WithLabelValues("1")))
+	require.Equal(t, float64(4096), testutil.ToFloat64(c.maxMemUsage.WithLabelValues("1")))
+
+	// A second call accumulates onto the counters rather than replacing them.
+	c.RecordNodeLevelStats(execstats.NodeLevelStats{
+		KVBytesReadGroupedByNode: map[roachpb.NodeID]int64{1: 50},
+	})
+	require.Equal(t, float64(150), testutil.ToFloat64(c.kvBytesRead.WithLabelValues("1")))
+}
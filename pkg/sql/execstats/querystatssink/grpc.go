@@ -0,0 +1,44 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package querystatssink
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+	"github.com/cockroachdb/errors"
+)
+
+// GRPCStatsClient is the minimal client-side contract needed to stream a
+// QueryStatsEvent to a remote collector. This package defines no gRPC
+// service or generated client stub; callers supply the implementation.
+type GRPCStatsClient interface {
+	EmitQueryStats(ctx context.Context, event *execstats.QueryStatsEvent) error
+}
+
+// GRPCSink is an execstats.QueryStatsSink that forwards every
+// QueryStatsEvent to a remote collector over gRPC.
+type GRPCSink struct {
+	client GRPCStatsClient
+}
+
+// NewGRPCSink constructs a GRPCSink that forwards events to client.
+func NewGRPCSink(client GRPCStatsClient) *GRPCSink {
+	return &GRPCSink{client: client}
+}
+
+// EmitQueryStats implements the execstats.QueryStatsSink interface.
+func (s *GRPCSink) EmitQueryStats(ctx context.Context, event execstats.QueryStatsEvent) error {
+	if err := s.client.EmitQueryStats(ctx, &event); err != nil {
+		return errors.Wrap(err, "error streaming query stats over gRPC")
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package querystatssink
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGRPCStatsClient struct {
+	received *execstats.QueryStatsEvent
+	err      error
+}
+
+func (c *fakeGRPCStatsClient) EmitQueryStats(_ context.Context, event *execstats.QueryStatsEvent) error {
+	c.received = event
+	return c.err
+}
+
+func TestGRPCSinkEmitQueryStats(t *testing.T) {
+	client := &fakeGRPCStatsClient{}
+	sink := NewGRPCSink(client)
+
+	event := execstats.QueryStatsEvent{QueryStatsMetadata: execstats.QueryStatsMetadata{QueryID: "q1"}}
+	require.NoError(t, sink.EmitQueryStats(context.Background(), event))
+	require.NotNil(t, client.received)
+	require.Equal(t, "q1", client.received.QueryID)
+}
+
+func TestGRPCSinkEmitQueryStatsError(t *testing.T) {
+	client := &fakeGRPCStatsClient{err: errors.New("boom")}
+	sink := NewGRPCSink(client)
+
+	err := sink.EmitQueryStats(context.Background(), execstats.QueryStatsEvent{})
+	require.Error(t, err)
+}
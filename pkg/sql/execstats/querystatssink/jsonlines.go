@@ -0,0 +1,45 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package querystatssink provides execstats.QueryStatsSink implementations
+// that stream per-query execution telemetry to external log/metric
+// pipelines instead of only exposing it through EXPLAIN ANALYZE.
+package querystatssink
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+)
+
+// JSONLinesSink is an execstats.QueryStatsSink that writes one JSON object
+// per QueryStatsEvent to an underlying writer, newline-delimited, so the
+// stream can be tailed or shipped by any standard log collector.
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesSink constructs a JSONLinesSink that writes to w. Writes are
+// serialized under a mutex since w may be shared across concurrently
+// executing queries.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// EmitQueryStats implements the execstats.QueryStatsSink interface.
+func (s *JSONLinesSink) EmitQueryStats(_ context.Context, event execstats.QueryStatsEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return json.NewEncoder(s.w).Encode(event)
+}
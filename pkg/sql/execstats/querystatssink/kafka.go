@@ -0,0 +1,70 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package querystatssink
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Shopify/sarama"
+	"github.com/cockroachdb/cockroach/pkg/sql/execstats"
+	"github.com/cockroachdb/errors"
+)
+
+// KafkaProducer is the minimal producer-side contract needed to publish a
+// serialized QueryStatsEvent to a Kafka topic. It is not satisfied directly
+// by github.com/Shopify/sarama's SyncProducer, whose SendMessage takes a
+// single *sarama.ProducerMessage rather than separate topic/key/value
+// arguments; wrap one in SaramaSyncProducer to bridge the two.
+type KafkaProducer interface {
+	SendMessage(topic string, key, value []byte) (partition int32, offset int64, err error)
+}
+
+// SaramaSyncProducer adapts a sarama.SyncProducer to the KafkaProducer
+// interface so it can be passed to NewKafkaSink.
+type SaramaSyncProducer struct {
+	Producer sarama.SyncProducer
+}
+
+// SendMessage implements the KafkaProducer interface.
+func (p SaramaSyncProducer) SendMessage(
+	topic string, key, value []byte,
+) (partition int32, offset int64, err error) {
+	return p.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.ByteEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	})
+}
+
+// KafkaSink is an execstats.QueryStatsSink that publishes every
+// QueryStatsEvent, JSON-encoded and keyed by query ID, to a Kafka topic.
+type KafkaSink struct {
+	producer KafkaProducer
+	topic    string
+}
+
+// NewKafkaSink constructs a KafkaSink that publishes to topic via producer.
+func NewKafkaSink(producer KafkaProducer, topic string) *KafkaSink {
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+// EmitQueryStats implements the execstats.QueryStatsSink interface.
+func (s *KafkaSink) EmitQueryStats(_ context.Context, event execstats.QueryStatsEvent) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling query stats event")
+	}
+	if _, _, err := s.producer.SendMessage(s.topic, []byte(event.QueryID), value); err != nil {
+		return errors.Wrap(err, "error publishing query stats event to Kafka")
+	}
+	return nil
+}
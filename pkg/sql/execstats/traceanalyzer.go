@@ -11,6 +11,7 @@
 package execstats
 
 import (
+	"context"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -25,6 +26,15 @@ type processorStats struct {
 	stats  *execinfrapb.ComponentStats
 }
 
+// ProcessorStats holds the raw per-processor KVTime and ContentionTime
+// observations used to build the percentile breakdowns in NodeLevelStats
+// and QueryLevelStats. See TraceAnalyzer.GetProcessorLevelStats.
+type ProcessorStats struct {
+	NodeID         roachpb.NodeID
+	KVTime         time.Duration
+	ContentionTime time.Duration
+}
+
 type streamStats struct {
 	originNodeID      roachpb.NodeID
 	destinationNodeID roachpb.NodeID
@@ -94,6 +104,47 @@ type NodeLevelStats struct {
 	KVTimeGroupedByNode           map[roachpb.NodeID]time.Duration
 	NetworkMessagesGroupedByNode  map[roachpb.NodeID]int64
 	ContentionTimeGroupedByNode   map[roachpb.NodeID]time.Duration
+
+	// KVTimePercentilesGroupedByNode and ContentionTimePercentilesGroupedByNode
+	// hold the p50/p95/p99 KVTime/ContentionTime across the processors that
+	// ran on each node, computed from that node's per-processor observations
+	// in the processorStats loop of ProcessStats. They are exported fields
+	// rather than digest-backed accessor methods so that sinks which
+	// JSON-marshal a NodeLevelStats wholesale (see the querystatssink
+	// package) actually carry the percentile breakdown.
+	KVTimePercentilesGroupedByNode         map[roachpb.NodeID]DurationPercentiles
+	ContentionTimePercentilesGroupedByNode map[roachpb.NodeID]DurationPercentiles
+
+	// kvTimeDigestGroupedByNode and contentionTimeDigestGroupedByNode hold a
+	// mergeable digest per node, built from that node's per-processor
+	// KVTime/ContentionTime observations. ProcessStats reduces these to
+	// KVTimePercentilesGroupedByNode/ContentionTimePercentilesGroupedByNode
+	// once every processor has been accounted for.
+	kvTimeDigestGroupedByNode         map[roachpb.NodeID]*durationDigest
+	contentionTimeDigestGroupedByNode map[roachpb.NodeID]*durationDigest
+
+	// Extended holds stats contributed by StatExtractors registered via
+	// RegisterExtractor, keyed by the name the extractor was registered
+	// under and then by node ID. The stats above are never duplicated
+	// here. Extended is nil until the first call to AddExtended;
+	// StatExtractor.Extract implementations must go through AddExtended
+	// rather than writing to this map directly, since ProcessStats does not
+	// pre-allocate it.
+	Extended map[string]map[roachpb.NodeID]float64
+}
+
+// AddExtended adds v to the value Extended stores for name and nodeID,
+// lazily allocating Extended and its per-name map as needed. StatExtractor
+// implementations should call this from Extract instead of indexing into
+// Extended directly.
+func (s *NodeLevelStats) AddExtended(name string, nodeID roachpb.NodeID, v float64) {
+	if s.Extended == nil {
+		s.Extended = make(map[string]map[roachpb.NodeID]float64)
+	}
+	if s.Extended[name] == nil {
+		s.Extended[name] = make(map[roachpb.NodeID]float64)
+	}
+	s.Extended[name][nodeID] += v
 }
 
 // QueryLevelStats returns all the query level stats that correspond to the
@@ -107,6 +158,42 @@ type QueryLevelStats struct {
 	KVTime           time.Duration
 	NetworkMessages  int64
 	ContentionTime   time.Duration
+
+	// KVTimePercentiles and ContentionTimePercentiles hold the p50/p95/p99
+	// KVTime/ContentionTime across every processor that contributed to this
+	// QueryLevelStats. They are exported fields, refreshed by Accumulate and
+	// by ProcessStats whenever kvTimeDigest/contentionTimeDigest change, so
+	// that sinks which JSON-marshal a QueryLevelStats wholesale (see the
+	// querystatssink package) actually carry the percentile breakdown
+	// instead of silently dropping it.
+	KVTimePercentiles         DurationPercentiles
+	ContentionTimePercentiles DurationPercentiles
+
+	// kvTimeDigest and contentionTimeDigest accumulate per-processor
+	// KVTime/ContentionTime observations across every node that
+	// contributed to this QueryLevelStats. They are merged associatively in
+	// Accumulate so KVTimePercentiles/ContentionTimePercentiles remain
+	// accurate regardless of how many FlowMetadatas were folded together.
+	kvTimeDigest         *durationDigest
+	contentionTimeDigest *durationDigest
+
+	// Extended holds query-level totals contributed by StatExtractors
+	// registered via RegisterExtractor, keyed by the name the extractor was
+	// registered under. Extended is nil until the first call to
+	// AddExtended or Accumulate; StatExtractor.Accumulate implementations
+	// must go through AddExtended rather than writing to this map
+	// directly, since it isn't pre-allocated.
+	Extended map[string]float64
+}
+
+// AddExtended adds v to the value Extended stores for name, lazily
+// allocating Extended as needed. StatExtractor implementations should call
+// this from Accumulate instead of indexing into Extended directly.
+func (s *QueryLevelStats) AddExtended(name string, v float64) {
+	if s.Extended == nil {
+		s.Extended = make(map[string]float64)
+	}
+	s.Extended[name] += v
 }
 
 // Accumulate accumulates other's stats into the receiver.
@@ -120,6 +207,24 @@ func (s *QueryLevelStats) Accumulate(other QueryLevelStats) {
 	s.KVTime += other.KVTime
 	s.NetworkMessages += other.NetworkMessages
 	s.ContentionTime += other.ContentionTime
+	s.kvTimeDigest = mergeDigests(s.kvTimeDigest, other.kvTimeDigest)
+	s.contentionTimeDigest = mergeDigests(s.contentionTimeDigest, other.contentionTimeDigest)
+	s.KVTimePercentiles = percentilesFromDigest(s.kvTimeDigest)
+	s.ContentionTimePercentiles = percentilesFromDigest(s.contentionTimeDigest)
+
+	// Extended fields are accumulated table-driven so that stats
+	// contributed by new StatExtractors don't require touching this
+	// method.
+	for name, v := range other.Extended {
+		s.AddExtended(name, v)
+	}
+}
+
+// StatsCollector is implemented by sinks that want to observe the node
+// level stats computed by a TraceAnalyzer as soon as ProcessStats finishes,
+// e.g. to push them into a metrics exporter. See TraceAnalyzer.SetStatsCollector.
+type StatsCollector interface {
+	RecordNodeLevelStats(NodeLevelStats)
 }
 
 // TraceAnalyzer is a struct that helps calculate top-level statistics from a
@@ -130,8 +235,10 @@ func (s *QueryLevelStats) Accumulate(other QueryLevelStats) {
 //     bytesGroupedByNode, err := analyzer.GetNetworkBytesSent()
 type TraceAnalyzer struct {
 	*FlowMetadata
-	nodeLevelStats  NodeLevelStats
-	queryLevelStats QueryLevelStats
+	nodeLevelStats      NodeLevelStats
+	queryLevelStats     QueryLevelStats
+	processorLevelStats map[execinfrapb.ProcessorID]ProcessorStats
+	statsCollector      StatsCollector
 }
 
 // MakeTraceAnalyzer creates a TraceAnalyzer with the corresponding physical
@@ -144,41 +251,82 @@ func MakeTraceAnalyzer(flowMetadata *FlowMetadata) *TraceAnalyzer {
 	return a
 }
 
+// SetStatsCollector installs a StatsCollector that will be notified with the
+// node level stats at the end of every ProcessStats call, including one that
+// returns an error, e.g. to
+// export them via promexport.Collectors.
+func (a *TraceAnalyzer) SetStatsCollector(c StatsCollector) {
+	a.statsCollector = c
+}
+
+// componentFilers maps a component type to the function that files a
+// component's stats into the FlowMetadata map keyed by that type, replacing
+// the switch on component.Type that AddTrace used to have. It is a
+// package-level var rather than a method on StatExtractor because what it
+// dispatches to isn't a named stat computation (the concern StatExtractor
+// exists for): each entry routes into a different statically-typed map
+// (processorStats, streamStats, flowStats) owned by FlowMetadata, which
+// RegisterExtractor's callers have no business reaching into.
+var componentFilers = map[execinfrapb.ComponentID_Type]func(*TraceAnalyzer, execinfrapb.ComponentID, *execinfrapb.ComponentStats) error{
+	execinfrapb.ComponentID_PROCESSOR: fileProcessorStats,
+	execinfrapb.ComponentID_STREAM:    fileStreamStats,
+	execinfrapb.ComponentID_FLOW:      fileFlowStats,
+}
+
+func fileProcessorStats(
+	a *TraceAnalyzer, component execinfrapb.ComponentID, componentStats *execinfrapb.ComponentStats,
+) error {
+	id := component.ID
+	processorStats := a.processorStats[execinfrapb.ProcessorID(id)]
+	if processorStats == nil {
+		return errors.Errorf("trace has span for processor %d but the processor does not exist in the physical plan", id)
+	}
+	processorStats.stats = componentStats
+	return nil
+}
+
+func fileStreamStats(
+	a *TraceAnalyzer, component execinfrapb.ComponentID, componentStats *execinfrapb.ComponentStats,
+) error {
+	id := component.ID
+	streamStats := a.streamStats[execinfrapb.StreamID(id)]
+	if streamStats == nil {
+		return errors.Errorf("trace has span for stream %d but the stream does not exist in the physical plan", id)
+	}
+	streamStats.stats = componentStats
+	return nil
+}
+
+func fileFlowStats(
+	a *TraceAnalyzer, component execinfrapb.ComponentID, componentStats *execinfrapb.ComponentStats,
+) error {
+	flowStats := a.flowStats[component.NodeID]
+	if flowStats == nil {
+		return errors.Errorf(
+			"trace has span for flow %s on node %s but the flow does not exist in the physical plan",
+			component.FlowID,
+			component.NodeID,
+		)
+	}
+	flowStats.stats = append(flowStats.stats, componentStats)
+	return nil
+}
+
 // AddTrace adds the stats from the given trace to the TraceAnalyzer.
 //
 // If makeDeterministic is set, statistics that can vary from run to run are set
 // to fixed values; see ComponentStats.MakeDeterministic.
 func (a *TraceAnalyzer) AddTrace(trace []tracingpb.RecordedSpan, makeDeterministic bool) error {
 	m := execinfrapb.ExtractStatsFromSpans(trace, makeDeterministic)
-	// Annotate the maps with stats extracted from the trace.
+	// Annotate the maps with stats extracted from the trace, dispatching on
+	// component.Type through componentFilers instead of a hard-coded switch.
 	for component, componentStats := range m {
-		switch component.Type {
-		case execinfrapb.ComponentID_PROCESSOR:
-			id := component.ID
-			processorStats := a.processorStats[execinfrapb.ProcessorID(id)]
-			if processorStats == nil {
-				return errors.Errorf("trace has span for processor %d but the processor does not exist in the physical plan", id)
-			}
-			processorStats.stats = componentStats
-
-		case execinfrapb.ComponentID_STREAM:
-			id := component.ID
-			streamStats := a.streamStats[execinfrapb.StreamID(id)]
-			if streamStats == nil {
-				return errors.Errorf("trace has span for stream %d but the stream does not exist in the physical plan", id)
-			}
-			streamStats.stats = componentStats
-
-		case execinfrapb.ComponentID_FLOW:
-			flowStats := a.flowStats[component.NodeID]
-			if flowStats == nil {
-				return errors.Errorf(
-					"trace has span for flow %s on node %s but the flow does not exist in the physical plan",
-					component.FlowID,
-					component.NodeID,
-				)
-			}
-			flowStats.stats = append(flowStats.stats, componentStats)
+		file, ok := componentFilers[component.Type]
+		if !ok {
+			continue
+		}
+		if err := file(a, component, componentStats); err != nil {
+			return err
 		}
 	}
 
@@ -198,18 +346,57 @@ func (a *TraceAnalyzer) ProcessStats() error {
 		KVTimeGroupedByNode:           make(map[roachpb.NodeID]time.Duration),
 		NetworkMessagesGroupedByNode:  make(map[roachpb.NodeID]int64),
 		ContentionTimeGroupedByNode:   make(map[roachpb.NodeID]time.Duration),
+
+		kvTimeDigestGroupedByNode:         make(map[roachpb.NodeID]*durationDigest),
+		contentionTimeDigestGroupedByNode: make(map[roachpb.NodeID]*durationDigest),
 	}
+	a.processorLevelStats = make(map[execinfrapb.ProcessorID]ProcessorStats)
 	var errs error
 
 	// Process processorStats.
-	for _, stats := range a.processorStats {
+	for id, stats := range a.processorStats {
 		if stats.stats == nil {
 			continue
 		}
+		kvTime := stats.stats.KV.KVTime.Value()
+		contentionTime := stats.stats.KV.ContentionTime.Value()
+
 		a.nodeLevelStats.KVBytesReadGroupedByNode[stats.nodeID] += int64(stats.stats.KV.BytesRead.Value())
 		a.nodeLevelStats.KVRowsReadGroupedByNode[stats.nodeID] += int64(stats.stats.KV.TuplesRead.Value())
-		a.nodeLevelStats.KVTimeGroupedByNode[stats.nodeID] += stats.stats.KV.KVTime.Value()
-		a.nodeLevelStats.ContentionTimeGroupedByNode[stats.nodeID] += stats.stats.KV.ContentionTime.Value()
+		a.nodeLevelStats.KVTimeGroupedByNode[stats.nodeID] += kvTime
+		a.nodeLevelStats.ContentionTimeGroupedByNode[stats.nodeID] += contentionTime
+
+		// Accumulate this processor's KVTime/ContentionTime into a
+		// per-node digest so that KVTimePercentiles/ContentionTimePercentiles
+		// can report tail latency across a node's processors, not just the
+		// per-node sum.
+		if a.nodeLevelStats.kvTimeDigestGroupedByNode[stats.nodeID] == nil {
+			a.nodeLevelStats.kvTimeDigestGroupedByNode[stats.nodeID] = &durationDigest{}
+		}
+		a.nodeLevelStats.kvTimeDigestGroupedByNode[stats.nodeID].Add(kvTime, 1)
+		if a.nodeLevelStats.contentionTimeDigestGroupedByNode[stats.nodeID] == nil {
+			a.nodeLevelStats.contentionTimeDigestGroupedByNode[stats.nodeID] = &durationDigest{}
+		}
+		a.nodeLevelStats.contentionTimeDigestGroupedByNode[stats.nodeID].Add(contentionTime, 1)
+
+		a.processorLevelStats[id] = ProcessorStats{
+			NodeID:         stats.nodeID,
+			KVTime:         kvTime,
+			ContentionTime: contentionTime,
+		}
+
+		a.runExtractors(execinfrapb.ComponentID_PROCESSOR, stats.nodeID, stats.stats)
+	}
+
+	// Reduce the per-node digests built above into exported percentile
+	// fields now that every processor has been accounted for.
+	a.nodeLevelStats.KVTimePercentilesGroupedByNode = make(map[roachpb.NodeID]DurationPercentiles, len(a.nodeLevelStats.kvTimeDigestGroupedByNode))
+	for nodeID, digest := range a.nodeLevelStats.kvTimeDigestGroupedByNode {
+		a.nodeLevelStats.KVTimePercentilesGroupedByNode[nodeID] = percentilesFromDigest(digest)
+	}
+	a.nodeLevelStats.ContentionTimePercentilesGroupedByNode = make(map[roachpb.NodeID]DurationPercentiles, len(a.nodeLevelStats.contentionTimeDigestGroupedByNode))
+	for nodeID, digest := range a.nodeLevelStats.contentionTimeDigestGroupedByNode {
+		a.nodeLevelStats.ContentionTimePercentilesGroupedByNode[nodeID] = percentilesFromDigest(digest)
 	}
 
 	// Process streamStats.
@@ -245,6 +432,8 @@ func (a *TraceAnalyzer) ProcessStats() error {
 		} else {
 			a.nodeLevelStats.NetworkMessagesGroupedByNode[stats.originNodeID] += numMessages
 		}
+
+		a.runExtractors(execinfrapb.ComponentID_STREAM, stats.originNodeID, stats.stats)
 	}
 
 	// Process flowStats.
@@ -262,49 +451,76 @@ func (a *TraceAnalyzer) ProcessStats() error {
 					a.nodeLevelStats.MaxMemoryUsageGroupedByNode[nodeID] = memUsage
 				}
 			}
+			a.runExtractors(execinfrapb.ComponentID_FLOW, nodeID, v)
 		}
 	}
 
 	// Process query level stats.
-	a.queryLevelStats = QueryLevelStats{
-		NetworkBytesSent: int64(0),
-		MaxMemUsage:      int64(0),
-		KVBytesRead:      int64(0),
-		KVRowsRead:       int64(0),
-		KVTime:           time.Duration(0),
-		NetworkMessages:  int64(0),
+	a.queryLevelStats = sumQueryLevelStats(a.nodeLevelStats)
+
+	// Let every registered StatExtractor fold the node-level values it
+	// extracted above into query-level stats. This is what allows new named
+	// stats to be added via RegisterExtractor without touching ProcessStats
+	// or QueryLevelStats.Accumulate.
+	for _, e := range registeredExtractors {
+		e.Accumulate(a.nodeLevelStats, &a.queryLevelStats)
 	}
 
-	for _, bytesSentByNode := range a.nodeLevelStats.NetworkBytesSentGroupedByNode {
-		a.queryLevelStats.NetworkBytesSent += bytesSentByNode
+	if a.statsCollector != nil {
+		a.statsCollector.RecordNodeLevelStats(a.nodeLevelStats)
 	}
+	return errs
+}
 
-	for _, maxMemUsage := range a.nodeLevelStats.MaxMemoryUsageGroupedByNode {
-		if maxMemUsage > a.queryLevelStats.MaxMemUsage {
-			a.queryLevelStats.MaxMemUsage = maxMemUsage
+// sumQueryLevelStats reduces a NodeLevelStats to the query-wide totals
+// ProcessStats stores in queryLevelStats. It is factored out of ProcessStats
+// so the aggregation itself can be unit tested against a NodeLevelStats
+// built by hand, without needing a real trace or FlowMetadata.
+func sumQueryLevelStats(node NodeLevelStats) QueryLevelStats {
+	var s QueryLevelStats
+	for _, bytesSentByNode := range node.NetworkBytesSentGroupedByNode {
+		s.NetworkBytesSent += bytesSentByNode
+	}
+	for _, maxMemUsage := range node.MaxMemoryUsageGroupedByNode {
+		if maxMemUsage > s.MaxMemUsage {
+			s.MaxMemUsage = maxMemUsage
 		}
 	}
-
-	for _, kvBytesRead := range a.nodeLevelStats.KVBytesReadGroupedByNode {
-		a.queryLevelStats.KVBytesRead += kvBytesRead
+	for _, kvBytesRead := range node.KVBytesReadGroupedByNode {
+		s.KVBytesRead += kvBytesRead
 	}
-
-	for _, kvRowsRead := range a.nodeLevelStats.KVRowsReadGroupedByNode {
-		a.queryLevelStats.KVRowsRead += kvRowsRead
+	for _, kvRowsRead := range node.KVRowsReadGroupedByNode {
+		s.KVRowsRead += kvRowsRead
 	}
-
-	for _, kvTime := range a.nodeLevelStats.KVTimeGroupedByNode {
-		a.queryLevelStats.KVTime += kvTime
+	for _, kvTime := range node.KVTimeGroupedByNode {
+		s.KVTime += kvTime
 	}
-
-	for _, networkMessages := range a.nodeLevelStats.NetworkMessagesGroupedByNode {
-		a.queryLevelStats.NetworkMessages += networkMessages
+	for _, networkMessages := range node.NetworkMessagesGroupedByNode {
+		s.NetworkMessages += networkMessages
+	}
+	for _, contentionTime := range node.ContentionTimeGroupedByNode {
+		s.ContentionTime += contentionTime
+	}
+	for _, digest := range node.kvTimeDigestGroupedByNode {
+		s.kvTimeDigest = mergeDigests(s.kvTimeDigest, digest)
 	}
+	for _, digest := range node.contentionTimeDigestGroupedByNode {
+		s.contentionTimeDigest = mergeDigests(s.contentionTimeDigest, digest)
+	}
+	s.KVTimePercentiles = percentilesFromDigest(s.kvTimeDigest)
+	s.ContentionTimePercentiles = percentilesFromDigest(s.contentionTimeDigest)
+	return s
+}
 
-	for _, contentionTime := range a.nodeLevelStats.KVTimeGroupedByNode {
-		a.queryLevelStats.ContentionTime += contentionTime
+// runExtractors dispatches stats to every StatExtractor registered for
+// component type t, in place of a hard-coded switch on
+// ComponentID_PROCESSOR/STREAM/FLOW.
+func (a *TraceAnalyzer) runExtractors(
+	t execinfrapb.ComponentID_Type, nodeID roachpb.NodeID, stats *execinfrapb.ComponentStats,
+) {
+	for _, e := range extractorsByComponentType[t] {
+		e.Extract(&a.nodeLevelStats, nodeID, stats)
 	}
-	return errs
 }
 
 func getNetworkBytesFromComponentStats(v *execinfrapb.ComponentStats) (int64, error) {
@@ -348,22 +564,77 @@ func (a *TraceAnalyzer) GetNodeLevelStats() NodeLevelStats {
 	return a.nodeLevelStats
 }
 
+// GetProcessorLevelStats returns the raw per-processor KVTime/ContentionTime
+// observations calculated and stored in the TraceAnalyzer, which back the
+// percentile breakdowns exposed by NodeLevelStats and QueryLevelStats.
+func (a *TraceAnalyzer) GetProcessorLevelStats() map[execinfrapb.ProcessorID]ProcessorStats {
+	return a.processorLevelStats
+}
+
 // GetQueryLevelStats returns the query level stats calculated and stored in TraceAnalyzer.
 func (a *TraceAnalyzer) GetQueryLevelStats() QueryLevelStats {
 	return a.queryLevelStats
 }
 
+// QueryStatsMetadata identifies the query a QueryStatsEvent was computed
+// for. It is supplied by the caller of GetQueryLevelStatsAndEmit, which
+// itself has no notion of queries, users, or applications.
+type QueryStatsMetadata struct {
+	QueryID   string
+	AppName   string
+	User      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// QueryStatsEvent is the structured event emitted to every QueryStatsSink
+// passed to GetQueryLevelStatsAndEmit once it finishes analyzing a query's
+// trace.
+type QueryStatsEvent struct {
+	QueryStatsMetadata
+	Stats     QueryLevelStats
+	NodeStats NodeLevelStats
+}
+
+// QueryStatsSink is implemented by backends that want to receive a
+// QueryStatsEvent for every query processed by GetQueryLevelStatsAndEmit
+// (GetQueryLevelStats emits to no sink), so that
+// execution telemetry can be streamed into external log or metric
+// pipelines in addition to being surfaced through EXPLAIN ANALYZE.
+type QueryStatsSink interface {
+	EmitQueryStats(ctx context.Context, event QueryStatsEvent) error
+}
+
 // GetQueryLevelStats returns all the top-level stats in a QueryLevelStats struct.
 // GetQueryLevelStats tries to process as many stats as possible. If errors occur
 // while processing stats, GetQueryLevelStats returns the combined errors to the caller
 // but continues calculating other stats.
 func GetQueryLevelStats(
 	trace []tracingpb.RecordedSpan, deterministicExplainAnalyze bool, flowMetadata []*FlowMetadata,
+) (QueryLevelStats, error) {
+	return GetQueryLevelStatsAndEmit(
+		context.Background(), trace, deterministicExplainAnalyze, flowMetadata, QueryStatsMetadata{},
+	)
+}
+
+// GetQueryLevelStatsAndEmit behaves like GetQueryLevelStats, and additionally
+// emits a QueryStatsEvent describing the query (using metadata) to every
+// given sink. It is kept separate from GetQueryLevelStats so that existing
+// callers don't need to thread a context and QueryStatsMetadata through
+// just to get top-level stats.
+func GetQueryLevelStatsAndEmit(
+	ctx context.Context,
+	trace []tracingpb.RecordedSpan,
+	deterministicExplainAnalyze bool,
+	flowMetadata []*FlowMetadata,
+	metadata QueryStatsMetadata,
+	sinks ...QueryStatsSink,
 ) (QueryLevelStats, error) {
 	var queryLevelStats QueryLevelStats
+	var nodeLevelStats NodeLevelStats
 	var errs error
-	for _, metadata := range flowMetadata {
-		analyzer := MakeTraceAnalyzer(metadata)
+	for _, fm := range flowMetadata {
+		analyzer := MakeTraceAnalyzer(fm)
 		if err := analyzer.AddTrace(trace, deterministicExplainAnalyze); err != nil {
 			errs = errors.CombineErrors(errs, errors.Wrap(err, "error analyzing trace statistics"))
 			continue
@@ -374,6 +645,18 @@ func GetQueryLevelStats(
 			continue
 		}
 		queryLevelStats = analyzer.GetQueryLevelStats()
+		nodeLevelStats = analyzer.GetNodeLevelStats()
+	}
+
+	for _, sink := range sinks {
+		event := QueryStatsEvent{
+			QueryStatsMetadata: metadata,
+			Stats:              queryLevelStats,
+			NodeStats:          nodeLevelStats,
+		}
+		if err := sink.EmitQueryStats(ctx, event); err != nil {
+			errs = errors.CombineErrors(errs, errors.Wrap(err, "error emitting query stats"))
+		}
 	}
 
 	return queryLevelStats, errs
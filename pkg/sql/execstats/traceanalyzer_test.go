@@ -0,0 +1,104 @@
+// Copyright 2020 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package execstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSumQueryLevelStatsContentionTime is a regression test for a bug where
+// ContentionTime was summed from KVTimeGroupedByNode instead of
+// ContentionTimeGroupedByNode, so a query's reported contention time always
+// equalled its KV time.
+func TestSumQueryLevelStatsContentionTime(t *testing.T) {
+	node := NodeLevelStats{
+		KVTimeGroupedByNode: map[roachpb.NodeID]time.Duration{
+			1: 10 * time.Second,
+			2: 20 * time.Second,
+		},
+		ContentionTimeGroupedByNode: map[roachpb.NodeID]time.Duration{
+			1: time.Second,
+			2: 2 * time.Second,
+		},
+	}
+
+	query := sumQueryLevelStats(node)
+	require.Equal(t, 30*time.Second, query.KVTime)
+	require.Equal(t, 3*time.Second, query.ContentionTime)
+	require.NotEqual(t, query.KVTime, query.ContentionTime)
+}
+
+// TestFileProcessorStatsUnknownProcessor verifies that filing stats for a
+// processor absent from the physical plan returns an error rather than
+// silently creating an entry, matching AddTrace's old switch-based behavior
+// now that filing goes through the componentFilers table.
+func TestFileProcessorStatsUnknownProcessor(t *testing.T) {
+	a := &TraceAnalyzer{FlowMetadata: &FlowMetadata{
+		processorStats: map[execinfrapb.ProcessorID]*processorStats{},
+	}}
+	err := fileProcessorStats(a, execinfrapb.ComponentID{ID: 1}, &execinfrapb.ComponentStats{})
+	require.Error(t, err)
+}
+
+// TestAddTraceDispatchesThroughComponentFilers verifies that AddTrace's
+// dispatch table has an entry for every component type the physical plan's
+// maps are keyed by, and that filing a processor's stats through it reaches
+// the same processorStats entry the old switch wrote to.
+func TestAddTraceDispatchesThroughComponentFilers(t *testing.T) {
+	for _, typ := range []execinfrapb.ComponentID_Type{
+		execinfrapb.ComponentID_PROCESSOR,
+		execinfrapb.ComponentID_STREAM,
+		execinfrapb.ComponentID_FLOW,
+	} {
+		require.Contains(t, componentFilers, typ)
+	}
+
+	stats := &processorStats{nodeID: 1}
+	a := &TraceAnalyzer{FlowMetadata: &FlowMetadata{
+		processorStats: map[execinfrapb.ProcessorID]*processorStats{5: stats},
+	}}
+	componentStats := &execinfrapb.ComponentStats{}
+	require.NoError(t, componentFilers[execinfrapb.ComponentID_PROCESSOR](
+		a, execinfrapb.ComponentID{ID: 5}, componentStats,
+	))
+	require.Same(t, componentStats, stats.stats)
+}
+
+// TestNodeLevelStatsAddExtended verifies that AddExtended lazily allocates
+// Extended and its per-name map instead of requiring callers (i.e.
+// StatExtractor.Extract implementations) to pre-allocate either level.
+func TestNodeLevelStatsAddExtended(t *testing.T) {
+	var node NodeLevelStats
+	node.AddExtended("foo", 1, 1.5)
+	node.AddExtended("foo", 1, 2.5)
+	node.AddExtended("foo", 2, 1.0)
+	node.AddExtended("bar", 1, 9.0)
+
+	require.Equal(t, 4.0, node.Extended["foo"][1])
+	require.Equal(t, 1.0, node.Extended["foo"][2])
+	require.Equal(t, 9.0, node.Extended["bar"][1])
+}
+
+// TestQueryLevelStatsAddExtended verifies that AddExtended lazily allocates
+// Extended instead of requiring callers (i.e. StatExtractor.Accumulate
+// implementations) to pre-allocate it.
+func TestQueryLevelStatsAddExtended(t *testing.T) {
+	var query QueryLevelStats
+	query.AddExtended("foo", 1.5)
+	query.AddExtended("foo", 2.5)
+
+	require.Equal(t, 4.0, query.Extended["foo"])
+}